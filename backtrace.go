@@ -0,0 +1,92 @@
+// File backtrace.go implements "-log-backtrace-at", which dumps a stack
+// trace every time a specific source location logs, without requiring a
+// redeploy.
+
+package logging
+
+import (
+	"flag"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	backtraceMu   sync.RWMutex
+	backtraceLocs map[string]struct{}
+)
+
+func init() {
+	flag.Var(backtraceAtValue{}, "log-backtrace-at", "comma-separated list of "+
+		"file:line locations; logging a line there also logs a backtrace")
+}
+
+// backtraceAtValue backs the "-log-backtrace-at" flag: setting the flag calls
+// SetBacktraceLocations.
+type backtraceAtValue struct{}
+
+func (backtraceAtValue) String() string { return "" }
+func (backtraceAtValue) Set(s string) error {
+	SetBacktraceLocations(splitNonEmpty(s, ","))
+	return nil
+}
+
+func splitNonEmpty(s, sep string) []string {
+	if s == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// SetBacktraceLocations installs the set of "file:line" locations that
+// trigger a backtrace dump when hit.
+func SetBacktraceLocations(locs []string) {
+	m := make(map[string]struct{}, len(locs))
+	for _, loc := range locs {
+		m[loc] = struct{}{}
+	}
+	backtraceMu.Lock()
+	backtraceLocs = m
+	backtraceMu.Unlock()
+}
+
+func backtraceWanted(file string, line int) bool {
+	backtraceMu.RLock()
+	defer backtraceMu.RUnlock()
+	if len(backtraceLocs) == 0 {
+		return false
+	}
+	_, ok := backtraceLocs[file+":"+strconv.Itoa(line)]
+	return ok
+}
+
+// captureBacktrace returns an indented stack trace of the current
+// goroutine, leaving out every frame inside package logging.
+func captureBacktrace() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if srcFile := srcFilePath(frame.File); !strings.HasPrefix(srcFile, "logging/") {
+			b.WriteString("\n\t")
+			b.WriteString(srcFile)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+			b.WriteByte(' ')
+			b.WriteString(frame.Function)
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
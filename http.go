@@ -0,0 +1,220 @@
+// File http.go exposes the logs over HTTP: recent-range queries built on
+// top of the existing LogLines machinery, and a live "follow" stream fed by
+// a small in-process pub/sub that processLogs publishes every line to.
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Handler serves the package-level redirector (see RedirectTo) over HTTP.
+// It returns 503 if RedirectTo hasn't been called yet.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lr == nil {
+			http.Error(w, "logging: no redirector configured", http.StatusServiceUnavailable)
+			return
+		}
+		lr.serveHTTP(w, r)
+	})
+}
+
+// Handler serves l's logs over HTTP with three modes, selected by query
+// parameter:
+//   - "since=<unix>&bytes=N" — lines logged at or after the timestamp
+//   - "before=<unix>&bytes=N" — lines logged before the timestamp
+//   - "follow=1" — a streaming response (Server-Sent Events if the request
+//     Accepts "text/event-stream", chunked plain text otherwise) that
+//     pushes new lines as they're logged
+//
+// All three modes accept "re=<regexp>" to keep only matching lines.
+// "follow=1" additionally accepts "minlevel=<N>" to drop lines less
+// important than N (lower is more important: -1 is error, 0 is warning);
+// minlevel can't be honored for the range modes since persisted log lines
+// don't retain their numeric level.
+func (l *LogRedirector) Handler() http.Handler {
+	return http.HandlerFunc(l.serveHTTP)
+}
+
+func (l *LogRedirector) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var re *regexp.Regexp
+	if pat := q.Get("re"); pat != "" {
+		compiled, err := regexp.Compile(pat)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid re: %v", err), http.StatusBadRequest)
+			return
+		}
+		re = compiled
+	}
+
+	if q.Get("follow") == "1" {
+		minLevel := 1<<31 - 1
+		if s := q.Get("minlevel"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid minlevel: %v", err), http.StatusBadRequest)
+				return
+			}
+			minLevel = v
+		}
+		serveFollow(w, r, re, minLevel)
+		return
+	}
+
+	numBytes := int64(1 << 20)
+	if s := q.Get("bytes"); s != "" {
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			numBytes = v
+		}
+	}
+	var startTs int64
+	if s := q.Get("since"); s != "" {
+		startTs, _ = strconv.ParseInt(s, 10, 64)
+	} else if s := q.Get("before"); s != "" {
+		v, _ := strconv.ParseInt(s, 10, 64)
+		startTs = -v
+	}
+
+	var lines []string
+	for _, line := range l.LogLines(startTs, numBytes) {
+		if re == nil || re.MatchString(line) {
+			lines = append(lines, line)
+		}
+	}
+	writeLines(w, r, lines)
+}
+
+// writeLines renders lines as JSON if the client asked for it via
+// "Accept: application/json", plain text otherwise.
+func writeLines(w http.ResponseWriter, r *http.Request, lines []string) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lines)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+func serveFollow(w http.ResponseWriter, r *http.Request, re *regexp.Regexp, minLevel int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sub := subscribeFollow()
+	defer unsubscribeFollow(sub)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case fe := <-sub.ch:
+			if fe.level > minLevel {
+				continue
+			}
+			if re != nil && !re.MatchString(fe.text) {
+				continue
+			}
+			if sse {
+				fmt.Fprintf(w, "data: %s\n\n", fe.text)
+			} else {
+				fmt.Fprintln(w, fe.text)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// followEntry is what's broadcast to "follow" subscribers: the fully
+// formatted line plus the level it was logged at, so a subscriber can
+// apply "minlevel" before the line is written to its response.
+type followEntry struct {
+	text  string
+	level int
+}
+
+type followSub struct {
+	ch chan followEntry
+}
+
+// followBufSize bounds each subscriber's backlog; once full, publishFollow
+// drops the oldest buffered line to make room for the newest one so a slow
+// HTTP client can't block logging.
+const followBufSize = 256
+
+var (
+	followMu   sync.Mutex
+	followSubs []*followSub
+)
+
+func subscribeFollow() *followSub {
+	s := &followSub{ch: make(chan followEntry, followBufSize)}
+	followMu.Lock()
+	followSubs = append(followSubs, s)
+	followMu.Unlock()
+	return s
+}
+
+func unsubscribeFollow(s *followSub) {
+	followMu.Lock()
+	defer followMu.Unlock()
+	for i, sub := range followSubs {
+		if sub == s {
+			followSubs = append(followSubs[:i], followSubs[i+1:]...)
+			break
+		}
+	}
+}
+
+func publishFollow(text string, level int) {
+	followMu.Lock()
+	defer followMu.Unlock()
+	for _, s := range followSubs {
+		fe := followEntry{text: text, level: level}
+		select {
+		case s.ch <- fe:
+		default:
+			// Bounded ring buffer: drop the oldest entry to make room.
+			select {
+			case <-s.ch:
+			default:
+			}
+			select {
+			case s.ch <- fe:
+			default:
+			}
+		}
+	}
+}
+
+// formatFollowLine renders e the same way a persisted log line reads, minus
+// the ANSI color codes (which make no sense over a text/JSON HTTP stream).
+func formatFollowLine(e Entry) string {
+	ts := e.Time.Format(LOG_LINE_TIME_FORMAT)
+	if e.File == "" {
+		return ts + " " + e.Message
+	}
+	return ts + " " + fileLinePrefix(e.File, e.Line) + e.Message
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeHTTPFiltersByRe(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "20200101-000000_20200101-000000_1.log")
+	content := "2020/01/01 00:00:01 [a.go:1] hello\n" +
+		"2020/01/01 00:00:02 [b.go:2] world ERROR\n"
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte(content), 0600))
+
+	l := &LogRedirector{logDir: dir, logFileName: logFile, stderr: os.Stderr}
+
+	req := httptest.NewRequest("GET", "/logs?re=ERROR", nil)
+	rec := httptest.NewRecorder()
+	l.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Body.String(), "world ERROR")
+	assert.NotContains(t, rec.Body.String(), "hello")
+}
+
+func TestServeHTTPInvalidRe(t *testing.T) {
+	l := &LogRedirector{logDir: t.TempDir(), stderr: os.Stderr}
+
+	req := httptest.NewRequest("GET", "/logs?re=[", nil)
+	rec := httptest.NewRecorder()
+	l.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, 400, rec.Code)
+}
+
+func TestServeHTTPInvalidMinlevel(t *testing.T) {
+	l := &LogRedirector{logDir: t.TempDir(), stderr: os.Stderr}
+
+	req := httptest.NewRequest("GET", "/logs?follow=1&minlevel=notanumber", nil)
+	rec := httptest.NewRecorder()
+	l.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, 400, rec.Code)
+}
@@ -9,12 +9,12 @@ import "C"
 import (
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -44,64 +44,113 @@ func init() {
 	nlogMap = make(map[string]int)
 }
 
-func Fatal(v ...interface{}) {
-	file, line := getFileLine()
+// FatalDepth is like Fatal but the reported file/line is "depth" frames up
+// from its caller (depth=0 reports the immediate caller of FatalDepth).
+// This lets wrapper libraries built on top of this package report the
+// location that actually matters to their own callers.
+func FatalDepth(depth int, v ...interface{}) {
+	file, line, _ := getFileLine(depth)
 	logText(file, line, -1, fmt.Sprint(v...))
 	Close()
 	os.Exit(1)
 }
 
-func Fatalf(format string, v ...interface{}) {
-	file, line := getFileLine()
+func FatalfDepth(depth int, format string, v ...interface{}) {
+	file, line, _ := getFileLine(depth)
 	logText(file, line, -1, fmt.Sprintf(format, v...))
 	Close()
 	os.Exit(1)
 }
 
-func Print(v ...interface{}) {
-	file, line := getFileLine()
+func Fatal(v ...interface{}) {
+	FatalDepth(1, v...)
+}
+
+func Fatalf(format string, v ...interface{}) {
+	FatalfDepth(1, format, v...)
+}
+
+// PrintDepth is like Print but the reported file/line is "depth" frames up
+// from its caller (depth=0 reports the immediate caller of PrintDepth).
+func PrintDepth(depth int, v ...interface{}) {
+	file, line, _ := getFileLine(depth)
 	logText(file, line, 1, fmt.Sprint(v...))
 }
 
-func Printf(format string, v ...interface{}) {
-	file, line := getFileLine()
+func Printfd(depth int, format string, v ...interface{}) {
+	file, line, _ := getFileLine(depth)
 	logText(file, line, 1, fmt.Sprintf(format, v...))
 }
 
+func Print(v ...interface{}) {
+	PrintDepth(1, v...)
+}
+
+func Printf(format string, v ...interface{}) {
+	Printfd(1, format, v...)
+}
+
 func VerboseLevel() int {
 	return int(C.verbose_log_level)
 }
 
 func SetVerboseLevel(level int) {
 	C.verbose_log_level = C.int(level)
+	bumpVModuleGen()
 }
 
-func Vlog(level int, v ...interface{}) {
-	if level <= VerboseLevel() {
-		file, line := getFileLine()
+// VlogDepth is like Vlog but the reported file/line (and the vmodule
+// lookup it drives) is "depth" frames up from its caller (depth=0 reports
+// the immediate caller of VlogDepth).
+func VlogDepth(depth, level int, v ...interface{}) {
+	// Fast path: with no -vmodule rules installed, VerboseLevel() alone is
+	// the effective level for every file, so a disabled call returns here
+	// without ever paying for the runtime.Caller() walk in getFileLine.
+	if level > VerboseLevel() && !vmoduleConfigured() {
+		return
+	}
+	file, line, pc := getFileLine(depth)
+	if level <= effectiveVerboseLevel(pc, file) {
 		logText(file, line, level, fmt.Sprint(v...))
 	}
 }
 
-func Vlogf(level int, format string, v ...interface{}) {
-	if level <= VerboseLevel() {
-		file, line := getFileLine()
+func VlogfDepth(depth, level int, format string, v ...interface{}) {
+	if level > VerboseLevel() && !vmoduleConfigured() {
+		return
+	}
+	file, line, pc := getFileLine(depth)
+	if level <= effectiveVerboseLevel(pc, file) {
 		logText(file, line, level, fmt.Sprintf(format, v...))
 	}
 }
 
+func Vlog(level int, v ...interface{}) {
+	VlogDepth(1, level, v...)
+}
+
+func Vlogf(level int, format string, v ...interface{}) {
+	VlogfDepth(1, level, format, v...)
+}
+
+// NlogDepth is like Nlog but the reported file/line is "depth" frames up
+// from its caller (depth=0 reports the immediate caller of NlogDepth).
+func NlogDepth(depth, n, level int, v ...interface{}) {
+	file, line, _ := getFileLine(depth)
+	if cnt := incNLogCnt(fileLinePrefix(file, line)); cnt%n == 1 {
+		VlogDepth(depth+1, level, v...)
+	}
+}
+
 // Log one every N times.
 func Nlog(n, level int, v ...interface{}) {
-	prefix := getFileLinePrefix()
-	if cnt := incNLogCnt(prefix); cnt%n == 1 {
-		Vlog(level, v...)
-	}
+	NlogDepth(1, n, level, v...)
 }
 
 func Nlogf(n, level int, format string, v ...interface{}) {
-	prefix := getFileLinePrefix()
-	if cnt := incNLogCnt(prefix); cnt%n == 1 {
-		Vlogf(level, format, v...)
+	file, line, _ := getFileLine(0)
+	if cnt := incNLogCnt(fileLinePrefix(file, line)); cnt%n == 1 {
+		VlogfDepth(1, level, format, v...)
 	}
 }
 
@@ -136,6 +185,12 @@ func (l *VLogger) Print(v ...interface{}) {
 	Vlog(l.level, v...)
 }
 
+// PrintDepth is like Print but the reported file/line is "depth" frames up
+// from its caller (depth=0 reports the immediate caller of PrintDepth).
+func (l *VLogger) PrintDepth(depth int, v ...interface{}) {
+	VlogDepth(depth+1, l.level, v...)
+}
+
 func (l *VLogger) Printf(format string, v ...interface{}) {
 	Vlogf(l.level, format, v...)
 }
@@ -171,19 +226,15 @@ func srcFilePath(path string) string {
 	return path
 }
 
-func getFileLine() (string, int) {
-	// Find the first caller outside of package logging.
-	for i := 2; ; i++ {
-		if _, file, line, ok := runtime.Caller(i); ok {
-			srcFile := srcFilePath(file)
-			if strings.HasPrefix(srcFile, "logging/") {
-				continue
-			}
-			return srcFile, line
-		}
-		break
+// getFileLine returns the source file / line "depth" frames up from its
+// caller (depth=0 reports the immediate caller of getFileLine's caller),
+// along with that call site's PC (used to cache per-call-site vmodule
+// lookups, see vmodule.go).
+func getFileLine(depth int) (string, int, uintptr) {
+	if pc, file, line, ok := runtime.Caller(depth + 2); ok {
+		return srcFilePath(file), line, pc
 	}
-	return "", 0
+	return "", 0, 0
 }
 
 func fileLinePrefix(file string, line int) string {
@@ -197,15 +248,14 @@ func fileLinePrefix(file string, line int) string {
 	return "[unknown] "
 }
 
-func getFileLinePrefix() string {
-	file, line := getFileLine()
-	return fileLinePrefix(file, line)
-}
-
 func logText(file string, line, level int, text string) {
+	backtrace := backtraceWanted(file, line)
+	if backtrace {
+		text += "\nGoroutine backtrace:" + captureBacktrace()
+	}
 	// Send to a channel instead of log directly so we could dedup.
 	select {
-	case logCh <- logEntry{file: file, line: line, level: level, text: text}:
+	case logCh <- logEntry{file: file, line: line, level: level, text: text, backtrace: backtrace}:
 	default:
 		atomic.AddUint32(&droppedLogLines, 1)
 	}
@@ -221,6 +271,7 @@ func Close() error {
 	close(logCh)
 	// Wait for "logCh" to be flushed.
 	wg.Wait()
+	closeSinks()
 	CloseRedirector()
 	return nil
 }
@@ -228,6 +279,10 @@ func Close() error {
 type logEntry struct {
 	file, text  string
 	line, level int
+	// backtrace is set when this entry carries a "-log-backtrace-at"
+	// backtrace; such entries always log (they bypass the "last line
+	// repeated" collapsing below) since every hit matters.
+	backtrace bool
 }
 
 func processLogs() {
@@ -236,20 +291,37 @@ func processLogs() {
 	var lastLogLineRepeatCount int
 	for le := range logCh {
 		if dropped := atomic.SwapUint32(&droppedLogLines, 0); dropped > 0 {
-			log.Printf("%s%d log lines were dropped.%s", COLOR_WARNING,
-				dropped, COLOR_NONE)
+			text := fmt.Sprintf("%d log lines were dropped.", dropped)
+			fanOut(adminEntry(fmt.Sprintf("%s%s%s", COLOR_WARNING, text, COLOR_NONE)))
+			publishFollow(text, 0)
 		}
-		if lastLogLine == le.text {
+		if !le.backtrace && lastLogLine == le.text {
 			lastLogLineRepeatCount++
 		} else {
 			if lastLogLineRepeatCount > 0 {
-				log.Printf("%sLast line repeated %d times.%s", COLOR_SUCCESS,
-					lastLogLineRepeatCount, COLOR_NONE)
+				text := fmt.Sprintf("Last line repeated %d times.", lastLogLineRepeatCount)
+				fanOut(adminEntry(fmt.Sprintf("%s%s%s", COLOR_SUCCESS, text, COLOR_NONE)))
+				publishFollow(text, 3)
 				lastLogLineRepeatCount = 0
 			}
 			lastLogLine = le.text
-			log.Print(fileLinePrefix(le.file, le.line), vlogPrefix(le.level), le.text,
-				vlogSuffix(le.level))
+			entry := Entry{
+				Time:      time.Now(),
+				File:      le.file,
+				Line:      le.line,
+				Level:     le.level,
+				Goroutine: goroutineID(),
+				Message:   le.text,
+			}
+			fanOut(entry)
+			publishFollow(formatFollowLine(entry), entry.Level)
 		}
 	}
 }
+
+// adminEntry wraps a pre-formatted, colorized administrative message (e.g.
+// "N log lines were dropped") as an Entry with no call site, so it goes
+// through the same fan-out as regular log lines.
+func adminEntry(text string) Entry {
+	return Entry{Time: time.Now(), Message: text}
+}
@@ -4,9 +4,11 @@ package logging
 
 import (
 	"bufio"
+	"compress/gzip"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -27,6 +29,11 @@ var (
 		"max-log-file-size", 10*1000*1000, "Switch to a new file if current log file is too big.")
 	maxLogDirSizeFlag = flag.Int64(
 		"max-log-dir-size", 1000*1000*1000, "Recycle old log files if the dir is at least this size")
+	maxLogFileAgeFlag = flag.Duration(
+		"max-log-file-age", 0,
+		"Delete log files older than this, regardless of -max-log-dir-size. 0 disables it.")
+	logCompressFlag = flag.Bool(
+		"log-compress", true, "Gzip a log file once it's rotated away from.")
 	alsoLogToStdoutFlag = flag.Bool("also-log-to-stdout", false, "Always write log to stdout.")
 	logChanSizeFlag     = flag.Int("log-chan-size", 100,
 		"Log is written by a separate goroutine to avoid slowing down logging caller. "+
@@ -35,6 +42,29 @@ var (
 
 var lr *LogRedirector
 
+func init() {
+	RegisterSink("file", fileSink{})
+}
+
+// fileSink is the built-in "file" sink: it preserves the pre-LogSink
+// behavior of writing through the standard "log" package, which is
+// redirected to a rotating local file by RedirectTo (and otherwise goes to
+// stderr).
+type fileSink struct{}
+
+func (fileSink) Emit(e Entry) error {
+	if e.File == "" {
+		// Pre-formatted administrative message, see adminEntry.
+		log.Print(e.Message)
+		return nil
+	}
+	log.Print(fileLinePrefix(e.File, e.Line), vlogPrefix(e.Level), e.Message, vlogSuffix(e.Level))
+	return nil
+}
+
+func (fileSink) Flush() error { return nil }
+func (fileSink) Close() error { return nil }
+
 func CloseRedirector() {
 	if lr != nil {
 		log.SetOutput(os.Stderr)
@@ -71,6 +101,9 @@ type LogRedirector struct {
 	stdout              *os.File
 	stderr              *os.File
 	wg                  sync.WaitGroup
+
+	compressCh chan string
+	compressWg sync.WaitGroup
 }
 
 func NewLogRedirector(logDir string) (*LogRedirector, error) {
@@ -78,24 +111,73 @@ func NewLogRedirector(logDir string) (*LogRedirector, error) {
 		return nil, err
 	}
 	lr := &LogRedirector{
-		logDir:    logDir,
-		startTime: time.Now(),
-		logCh:     make(chan []byte, *logChanSizeFlag),
+		logDir:     logDir,
+		startTime:  time.Now(),
+		logCh:      make(chan []byte, *logChanSizeFlag),
+		compressCh: make(chan string, 16),
 	}
 	lr.redirectToNewFile()
 	lr.stdout = os.NewFile(uintptr(C.old_stdout), "/dev/stdout")
 	lr.stderr = os.NewFile(uintptr(C.old_stderr), "/dev/stderr")
 	lr.wg.Add(1)
 	go lr.writeLog()
+	lr.compressWg.Add(1)
+	go lr.compressWorker()
 	return lr, nil
 }
 
 func (l *LogRedirector) Close() error {
 	close(l.logCh)
 	l.wg.Wait()
+	close(l.compressCh)
+	l.compressWg.Wait()
 	return nil
 }
 
+// compressWorker gzips rotated-away log files one at a time, so a burst of
+// rotations can't spike CPU with concurrent compressions.
+func (l *LogRedirector) compressWorker() {
+	defer l.compressWg.Done()
+	for path := range l.compressCh {
+		if err := compressLogFile(path); err != nil {
+			fmt.Fprintln(l.stderr, err)
+		}
+	}
+}
+
+// compressLogFile gzips path to path+".gz" and removes the original. The
+// caller must guarantee path is not the currently-open log file.
+func compressLogFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gzPath := path + ".gz"
+	out, err := os.OpenFile(gzPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		os.Remove(gzPath)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(gzPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(gzPath)
+		return err
+	}
+	return os.Remove(path)
+}
+
 var logChFullErr = errors.New("log channel is full")
 
 func (l *LogRedirector) Write(p []byte) (n int, err error) {
@@ -114,8 +196,9 @@ const (
 )
 
 var (
-	colorRe        = regexp.MustCompile("\033[[][;0-9]+m")
-	logFileNameRe  = regexp.MustCompile("^[0-9]{8}-[0-9]{6}_([0-9]{8}-[0-9]{6})_[0-9]+[.]log$")
+	colorRe       = regexp.MustCompile("\033[[][;0-9]+m")
+	logFileNameRe = regexp.MustCompile(
+		"^[0-9]{8}-[0-9]{6}_([0-9]{8}-[0-9]{6})_[0-9]+[.]log([.]gz)?$")
 	logLineStartRe = regexp.MustCompile("^([0-9]{4}/[0-9]{2}/[0-9]{2} [0-9]{2}:[0-9]{2}:[0-9]{2})")
 )
 
@@ -209,9 +292,41 @@ func (l *LogRedirector) LogLines(startTs, numBytes int64) []string {
 	return lines
 }
 
+// openLogFile opens a log file for reading, transparently decompressing it
+// if it was rotated away with a ".gz" suffix.
+func openLogFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{gz: gz, f: f}, nil
+}
+
+// gzipFile makes a gzip.Reader plus the *os.File backing it close together
+// as one io.ReadCloser.
+type gzipFile struct {
+	gz *gzip.Reader
+	f  *os.File
+}
+
+func (g *gzipFile) Read(p []byte) (int, error) { return g.gz.Read(p) }
+
+func (g *gzipFile) Close() error {
+	g.gz.Close()
+	return g.f.Close()
+}
+
 func (l *LogRedirector) logLinesAfter(fi os.FileInfo, startTs int64, numBytes *int64) []string {
 	logFile := filepath.Join(l.logDir, fi.Name())
-	file, err := os.Open(logFile)
+	file, err := openLogFile(logFile)
 	if err != nil {
 		Vlog(0, err)
 		return nil
@@ -242,7 +357,7 @@ func (l *LogRedirector) logLinesAfter(fi os.FileInfo, startTs int64, numBytes *i
 // If startTs <= 0, return log lines from the end of the file.
 func (l *LogRedirector) logLinesBefore(fi os.FileInfo, startTs int64, numBytes *int64) []string {
 	logFile := filepath.Join(l.logDir, fi.Name())
-	file, err := os.Open(logFile)
+	file, err := openLogFile(logFile)
 	if err != nil {
 		Vlog(0, err)
 		return nil
@@ -273,6 +388,7 @@ func (l *LogRedirector) redirectToNewFile() {
 	logFileBase := fmt.Sprintf("%s_%s_%d.log",
 		l.startTime.Format(LOG_FILE_TIME_FORMAT), time.Now().Format(LOG_FILE_TIME_FORMAT),
 		l.numLogFiles)
+	oldLogFileName := l.logFileName
 	if l.logFileName != "" {
 		l.writeToCStdout([]byte(fmt.Sprintf("Redirecting log to '%s'.\n", logFileBase)))
 	}
@@ -295,6 +411,15 @@ func (l *LogRedirector) redirectToNewFile() {
 		if *alsoLogToStdoutFlag {
 			fmt.Fprintf(l.stdout, "Redirecting log to '%s'.\n", logFileBase)
 		}
+
+		if oldLogFileName != "" && *logCompressFlag {
+			select {
+			case l.compressCh <- oldLogFileName:
+			default:
+				fmt.Fprintf(l.stderr, "Compress queue full, leaving '%s' uncompressed.\n",
+					oldLogFileName)
+			}
+		}
 	}
 }
 
@@ -347,6 +472,28 @@ func (l *LogRedirector) writeToCStdout(data []byte) int {
 	return int(C.write_to_log((*C.char)(unsafe.Pointer(bh.Data)), C.int(bh.Len)))
 }
 
+// deleteLogFilesOlderThan removes regular files (other than the current log
+// file) whose mod time is older than maxAge, and returns the remaining
+// FileInfos. Applied before the size-based eviction below.
+func (l *LogRedirector) deleteLogFilesOlderThan(fis []os.FileInfo, maxAge time.Duration) []os.FileInfo {
+	now := time.Now()
+	kept := fis[:0]
+	for _, fi := range fis {
+		file := filepath.Join(l.logDir, fi.Name())
+		if !fi.Mode().IsRegular() || file == l.logFileName || now.Sub(fi.ModTime()) <= maxAge {
+			kept = append(kept, fi)
+			continue
+		}
+		l.writeToCStdout([]byte(fmt.Sprintf("Deleting '%s' (age %s>%s)...\n",
+			file, now.Sub(fi.ModTime()), maxAge)))
+		if err := os.Remove(file); err != nil {
+			fmt.Fprintln(l.stderr, err)
+			kept = append(kept, fi)
+		}
+	}
+	return kept
+}
+
 // This function blocks writeLog. It avoids using the normal logging functions in order
 // to prevent deadlock.
 func (l *LogRedirector) recycleOldLogFiles() {
@@ -362,6 +509,11 @@ func (l *LogRedirector) recycleOldLogFiles() {
 		fmt.Fprintln(l.stderr, err)
 		return
 	}
+
+	if maxAge := *maxLogFileAgeFlag; maxAge > 0 {
+		fis = l.deleteLogFilesOlderThan(fis, maxAge)
+	}
+
 	var dirSize int64
 	for _, fi := range fis {
 		dirSize += fi.Size()
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressLogFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "20200101-000000_20200101-000000_1.log")
+	want := "2020/01/01 00:00:01 [a.go:1] hello\n2020/01/01 00:00:02 [b.go:2] world\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(want), 0600))
+
+	assert.NoError(t, compressLogFile(path))
+
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	gzPath := path + ".gz"
+	assert.FileExists(t, gzPath)
+
+	f, err := openLogFile(gzPath)
+	assert.NoError(t, err)
+	got, err := ioutil.ReadAll(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, want, string(got))
+}
+
+func TestCompressLogFileMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	assert.Error(t, compressLogFile(filepath.Join(dir, "does-not-exist.log")))
+}
+
+func TestOpenLogFilePlain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plain.log")
+	want := "uncompressed line\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(want), 0600))
+
+	f, err := openLogFile(path)
+	assert.NoError(t, err)
+	got, err := ioutil.ReadAll(f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, want, string(got))
+}
@@ -0,0 +1,156 @@
+// File sink.go lets logged entries fan out to one or more LogSink
+// implementations instead of only the local log file. See subpackages
+// sink/stderr, sink/syslog, sink/journald and sink/http for ready-made
+// sinks; register them (or your own) with RegisterSink.
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Entry is what gets handed to every registered LogSink. Sinks are free to
+// render it however they like (plain text, JSON, syslog PRI, ...).
+type Entry struct {
+	Time      time.Time
+	File      string
+	Line      int
+	Level     int
+	Goroutine int64
+	Message   string
+	KV        map[string]interface{}
+}
+
+// LogSink receives every logged Entry. Emit/Flush/Close errors are not
+// fatal: a broken sink only loses its own output, see RegisterSink.
+type LogSink interface {
+	Emit(e Entry) error
+	Flush() error
+	Close() error
+}
+
+// sinkChanBufSize bounds how many entries can be queued for a sink before
+// fanOut starts dropping for it. Each sink drains its own channel from its
+// own goroutine, so a sink that's slow (or outright stuck, e.g. a syslog
+// Write with no deadline or an HTTP POST mid-retry) only backs up its own
+// queue; it can't stall fanOut itself or any other sink, including the
+// built-in "file" one.
+const sinkChanBufSize = 256
+
+// registeredSink pairs a LogSink with the channel and goroutine that feed
+// it, decoupled from the caller of fanOut.
+type registeredSink struct {
+	sink  LogSink
+	ch    chan Entry
+	done  chan struct{}
+	drops uint32 // atomic; entries dropped because Emit erred or ch was full
+}
+
+func (rs *registeredSink) run(name string) {
+	defer close(rs.done)
+	for e := range rs.ch {
+		if err := rs.sink.Emit(e); err != nil {
+			atomic.AddUint32(&rs.drops, 1)
+			fmt.Fprintf(os.Stderr, "logging: sink %q dropped an entry: %v\n", name, err)
+		}
+	}
+}
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = map[string]*registeredSink{}
+)
+
+// RegisterSink adds (or replaces) a named sink that every subsequent logged
+// entry is fanned out to. The built-in file sink is pre-registered as
+// "file".
+func RegisterSink(name string, s LogSink) {
+	rs := &registeredSink{sink: s, ch: make(chan Entry, sinkChanBufSize), done: make(chan struct{})}
+	sinksMu.Lock()
+	sinks[name] = rs
+	sinksMu.Unlock()
+	go rs.run(name)
+}
+
+// UnregisterSink removes a previously registered sink, waiting for its
+// queue to drain before returning. It does not call Close on the sink.
+func UnregisterSink(name string) {
+	sinksMu.Lock()
+	rs, ok := sinks[name]
+	delete(sinks, name)
+	sinksMu.Unlock()
+	if ok {
+		close(rs.ch)
+		<-rs.done
+	}
+}
+
+// fanOut queues e for every registered sink without blocking: a sink whose
+// queue is full has this entry dropped and its drop counter bumped, same as
+// an Emit error.
+func fanOut(e Entry) {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	for name, rs := range sinks {
+		select {
+		case rs.ch <- e:
+		default:
+			atomic.AddUint32(&rs.drops, 1)
+			fmt.Fprintf(os.Stderr, "logging: sink %q queue full, dropped an entry\n", name)
+		}
+	}
+}
+
+func flushSinks() {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	for name, rs := range sinks {
+		if err := rs.sink.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "logging: sink %q failed to flush: %v\n", name, err)
+		}
+	}
+}
+
+// Flush flushes every registered sink (e.g. sink/http's batched POSTs)
+// without closing any of them, so a caller can force buffered output out at
+// a controlled point without tearing down logging via Close.
+func Flush() {
+	flushSinks()
+}
+
+// closeSinks stops every sink's worker goroutine (draining whatever is
+// still queued), then closes the sink itself.
+func closeSinks() {
+	sinksMu.Lock()
+	all := sinks
+	sinks = map[string]*registeredSink{}
+	sinksMu.Unlock()
+	for name, rs := range all {
+		close(rs.ch)
+		<-rs.done
+		if err := rs.sink.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "logging: sink %q failed to close: %v\n", name, err)
+		}
+	}
+}
+
+// goroutineID extracts the calling goroutine's id from its own stack trace
+// header ("goroutine 123 [running]:"). It's only ever used to annotate log
+// entries, so a parse failure just yields 0.
+func goroutineID() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	b := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,124 @@
+// Package http provides a LogSink that batches entries and POSTs them as
+// gzip-compressed JSON to a remote collector, retrying with exponential
+// backoff on failure.
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	stdhttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/YijinLiu/logging"
+)
+
+// Sink batches entries and POSTs them to a collector endpoint.
+type Sink struct {
+	url        string
+	batchSize  int
+	flushEvery time.Duration
+	client     *stdhttp.Client
+
+	mu    sync.Mutex
+	batch []logging.Entry
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New starts a sink that flushes whenever batchSize entries have
+// accumulated, or every flushEvery, whichever comes first.
+func New(url string, batchSize int, flushEvery time.Duration) *Sink {
+	s := &Sink{
+		url:        url,
+		batchSize:  batchSize,
+		flushEvery: flushEvery,
+		client:     &stdhttp.Client{Timeout: 10 * time.Second},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.stop:
+			s.Flush()
+			return
+		}
+	}
+}
+
+func (s *Sink) Emit(e logging.Entry) error {
+	s.mu.Lock()
+	s.batch = append(s.batch, e)
+	full := len(s.batch) >= s.batchSize
+	s.mu.Unlock()
+	if full {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *Sink) Flush() error {
+	s.mu.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.post(batch)
+}
+
+func (s *Sink) post(batch []logging.Entry) error {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	if err := json.NewEncoder(gz).Encode(batch); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	var lastErr error
+	backoff := 200 * time.Millisecond
+	for attempt := 0; attempt < 5; attempt++ {
+		req, err := stdhttp.NewRequest(stdhttp.MethodPost, s.url, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		resp, err := s.client.Do(req)
+		if err != nil {
+			lastErr = err
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return nil
+			}
+			lastErr = fmt.Errorf("http sink: unexpected status %d", resp.StatusCode)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return lastErr
+}
+
+// Close flushes any buffered entries and stops the background flusher.
+func (s *Sink) Close() error {
+	close(s.stop)
+	<-s.done
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package journald provides a LogSink that forwards entries to the local
+// systemd-journald daemon using its native datagram protocol.
+package journald
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/YijinLiu/logging"
+)
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// Sink forwards entries to journald over a unix datagram socket.
+type Sink struct {
+	conn net.Conn
+}
+
+// New dials the local journald socket.
+func New() (*Sink, error) {
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{conn: conn}, nil
+}
+
+func priority(level int) int {
+	switch {
+	case level < 0:
+		return 3 // LOG_ERR
+	case level == 0:
+		return 4 // LOG_WARNING
+	default:
+		return 6 // LOG_INFO
+	}
+}
+
+func (s *Sink) Emit(e logging.Entry) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "PRIORITY=%d\n", priority(e.Level))
+	fmt.Fprintf(&buf, "CODE_FILE=%s\n", e.File)
+	fmt.Fprintf(&buf, "CODE_LINE=%d\n", e.Line)
+	writeField(&buf, "MESSAGE", e.Message)
+	for k, v := range e.KV {
+		writeField(&buf, journalKeyName(k), fmt.Sprint(v))
+	}
+	_, err := s.conn.Write(buf.Bytes())
+	return err
+}
+
+// writeField encodes a field using journald's newline-safe wire format:
+// plain "KEY=VALUE\n" unless the value itself contains a newline, in which
+// case it's length-prefixed per the native protocol.
+func writeField(buf *bytes.Buffer, key, value string) {
+	if bytes.ContainsRune([]byte(value), '\n') {
+		buf.WriteString(key)
+		buf.WriteByte('\n')
+		var lenBuf [8]byte
+		putUint64LE(lenBuf[:], uint64(len(value)))
+		buf.Write(lenBuf[:])
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func putUint64LE(b []byte, v uint64) {
+	for i := range b {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// journalKeyName uppercases k and replaces any character journald doesn't
+// allow in a field name with '_'.
+func journalKeyName(k string) string {
+	out := make([]byte, len(k))
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			c = '_'
+		}
+		out[i] = c
+	}
+	return string(out)
+}
+
+func (s *Sink) Flush() error { return nil }
+func (s *Sink) Close() error { return s.conn.Close() }
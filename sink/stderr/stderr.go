@@ -0,0 +1,39 @@
+// Package stderr provides a LogSink that writes entries straight to
+// os.Stderr, independent of any file redirection. It supersedes the old
+// "-also-log-to-stdout" flag for callers that want an explicit stderr
+// mirror.
+package stderr
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/YijinLiu/logging"
+)
+
+// Sink writes every entry to os.Stderr.
+type Sink struct {
+	mu sync.Mutex
+}
+
+func New() *Sink {
+	return &Sink{}
+}
+
+func (s *Sink) Emit(e logging.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintln(os.Stderr, formatLine(e))
+	return err
+}
+
+func (s *Sink) Flush() error { return nil }
+func (s *Sink) Close() error { return nil }
+
+func formatLine(e logging.Entry) string {
+	if e.File == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("[%s:%d] %s", e.File, e.Line, e.Message)
+}
@@ -0,0 +1,62 @@
+// Package syslog provides a LogSink that forwards entries to a syslog
+// daemon as RFC 5424 messages over UDP, TCP or a unix socket.
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/YijinLiu/logging"
+)
+
+const facilityUser = 1 << 3
+
+// Sink forwards entries to a syslog endpoint.
+type Sink struct {
+	appName string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// New dials a syslog endpoint. network is "udp", "tcp" or "unix"; addr is
+// the matching address (e.g. "localhost:514" or "/dev/log"). appName is
+// used as the RFC 5424 APP-NAME field.
+func New(network, addr, appName string) (*Sink, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{appName: appName, conn: conn}, nil
+}
+
+func severity(level int) int {
+	switch {
+	case level < 0:
+		return 3 // error
+	case level == 0:
+		return 4 // warning
+	default:
+		return 6 // info
+	}
+}
+
+func (s *Sink) Emit(e logging.Entry) error {
+	pri := facilityUser | severity(e.Level)
+	msg := fmt.Sprintf("<%d>1 %s - %s - - - [%s:%d] %s\n", pri,
+		e.Time.UTC().Format(time.RFC3339), s.appName, e.File, e.Line, e.Message)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+func (s *Sink) Flush() error { return nil }
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.Close()
+}
@@ -0,0 +1,184 @@
+// File vmodule.go adds per-file verbose level overrides on top of the
+// global "-v" level, mirroring glog's "-vmodule" flag.
+
+package logging
+
+// #include "logging.h"
+import "C"
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	vmoduleFlag = flag.String("vmodule", "", "comma-separated list of pattern=N settings for "+
+		"per-file verbose logging, e.g. \"server=2,pkg/**/rpc.go=4\"")
+
+	vmoduleMu    sync.RWMutex
+	vmoduleRules []vmoduleRule
+	vmoduleGen   int32
+
+	// Caches the effective verbose level per call site (keyed by PC) so the
+	// glob matching below is only done once per generation.
+	vlogCache sync.Map // uintptr -> vlogCacheEntry
+)
+
+func init() {
+	if err := SetVModule(*vmoduleFlag); err != nil {
+		Fatal(err)
+	}
+}
+
+type vmoduleRule struct {
+	base  string         // set for bare module-name patterns, e.g. "server"
+	re    *regexp.Regexp // set for slash-containing glob patterns
+	level int
+}
+
+type vlogCacheEntry struct {
+	gen   int32
+	level int32
+}
+
+// SetVModule parses a "-vmodule"-style spec and installs it, invalidating
+// the Vlog/Vlogf call-site cache.
+func SetVModule(spec string) error {
+	var rules []vmoduleRule
+	if spec != "" {
+		for _, entry := range strings.Split(spec, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			eq := strings.LastIndex(entry, "=")
+			if eq < 0 {
+				return fmt.Errorf("invalid vmodule entry %q, want pattern=N", entry)
+			}
+			level, err := strconv.Atoi(entry[eq+1:])
+			if err != nil {
+				return fmt.Errorf("invalid vmodule entry %q: %v", entry, err)
+			}
+			pattern := entry[:eq]
+			if strings.Contains(pattern, "/") {
+				rules = append(rules, vmoduleRule{re: compileGlob(pattern), level: level})
+			} else {
+				rules = append(rules, vmoduleRule{base: pattern, level: level})
+			}
+		}
+	}
+	vmoduleMu.Lock()
+	vmoduleRules = rules
+	vmoduleMu.Unlock()
+	bumpVModuleGen()
+	return nil
+}
+
+func bumpVModuleGen() {
+	atomic.AddInt32(&vmoduleGen, 1)
+}
+
+// vmoduleLookup returns the configured verbose level for file, or -1 if no
+// rule matches. Rules are tried in the order they were given; the first
+// match wins.
+func vmoduleLookup(file string) int {
+	vmoduleMu.RLock()
+	rules := vmoduleRules
+	vmoduleMu.RUnlock()
+	base := file
+	if slash := strings.LastIndex(base, "/"); slash >= 0 {
+		base = base[slash+1:]
+	}
+	base = strings.TrimSuffix(base, ".go")
+	for _, r := range rules {
+		if r.re != nil {
+			if r.re.MatchString(file) {
+				return r.level
+			}
+		} else if r.base == base {
+			return r.level
+		}
+	}
+	return -1
+}
+
+// vmoduleConfigured reports whether any -vmodule rules are currently
+// installed. Vlog/Vlogf use this to skip the runtime.Caller() call behind
+// getFileLine entirely when no rule could possibly raise a file's effective
+// level above the cheap global VerboseLevel() check.
+func vmoduleConfigured() bool {
+	vmoduleMu.RLock()
+	defer vmoduleMu.RUnlock()
+	return len(vmoduleRules) > 0
+}
+
+// compileGlob turns a shell-style glob into a regexp, treating "**" as
+// "match anything, including '/'" and a bare "*" as "match anything but
+// '/'". A "/**/" is special-cased to also match a single "/" (i.e. zero
+// intervening directories), so "pkg/**/rpc.go" matches "pkg/rpc.go" as
+// well as "pkg/a/b/rpc.go".
+func compileGlob(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteByte('^')
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "/**/"):
+			b.WriteString("/(?:.*/)?")
+			i += 4
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			b.WriteByte('.')
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			i++
+		}
+	}
+	b.WriteByte('$')
+	return regexp.MustCompile(b.String())
+}
+
+// uncachedEffectiveVerboseLevel returns max(VerboseLevel(), vmoduleLookup(file)).
+func uncachedEffectiveVerboseLevel(file string) int {
+	level := VerboseLevel()
+	if m := vmoduleLookup(file); m > level {
+		level = m
+	}
+	return level
+}
+
+// effectiveVerboseLevel is uncachedEffectiveVerboseLevel, cached per call
+// site (pc) until the vmodule generation changes.
+func effectiveVerboseLevel(pc uintptr, file string) int {
+	gen := atomic.LoadInt32(&vmoduleGen)
+	if v, ok := vlogCache.Load(pc); ok {
+		if entry := v.(vlogCacheEntry); entry.gen == gen {
+			return int(entry.level)
+		}
+	}
+	level := uncachedEffectiveVerboseLevel(file)
+	vlogCache.Store(pc, vlogCacheEntry{gen: gen, level: int32(level)})
+	return level
+}
+
+//export cVLogEnabled
+func cVLogEnabled(file *C.char, level C.int) C.int {
+	// Unlike Vlog/Vlogf, there's no single Go PC to key a cache entry by
+	// here (every C call site would collide on the same key), so just do
+	// the uncached lookup; it's a scan of the -vmodule rules with at most one
+	// regexp match per glob rule, cheap enough to pay on every call.
+	if int(level) <= uncachedEffectiveVerboseLevel(srcFilePath(C.GoString(file))) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileGlob(t *testing.T) {
+	re := compileGlob("server/home_server/*")
+	assert.True(t, re.MatchString("server/home_server/main_common.go"))
+	assert.False(t, re.MatchString("server/home_server/sub/main_common.go"))
+
+	re = compileGlob("pkg/**/rpc.go")
+	assert.True(t, re.MatchString("pkg/a/b/rpc.go"))
+	assert.True(t, re.MatchString("pkg/rpc.go"))
+	assert.False(t, re.MatchString("pkg/a/b/other.go"))
+}
+
+func TestSetVModuleAndLookup(t *testing.T) {
+	assert.NoError(t, SetVModule("server=2,pkg/**/rpc.go=4"))
+	defer SetVModule("")
+
+	assert.Equal(t, 2, vmoduleLookup("server/home_server/server.go"))
+	assert.Equal(t, 4, vmoduleLookup("pkg/a/b/rpc.go"))
+	assert.Equal(t, -1, vmoduleLookup("unrelated.go"))
+}
+
+func TestSetVModuleInvalid(t *testing.T) {
+	assert.Error(t, SetVModule("bad-entry-no-equals"))
+	assert.Error(t, SetVModule("server=notanumber"))
+}